@@ -0,0 +1,31 @@
+package crypto
+
+import (
+	"crypto/rand"
+
+	"github.com/PavloVaida/library-go/pkg/operator/encryption/state"
+)
+
+var (
+	// ModeToNewKeyFunc mints new key material for a given mode. state.KMS has no entry
+	// here: a KMS keyspace is identified by the plugin endpoint it talks to, which is
+	// operator-configured rather than randomly minted.
+	ModeToNewKeyFunc = map[state.Mode]func() []byte{
+		state.AESCBC:    NewAES256Key,
+		state.AESGCM:    NewAES256Key, // AES-GCM accepts 16, 24 or 32 byte keys; we always use 32
+		state.SecretBox: NewAES256Key, // secretbox requires a 32 byte key so we can reuse the same function here
+		state.Identity:  NewIdentityKey,
+	}
+)
+
+func NewAES256Key() []byte {
+	b := make([]byte, 32) // AES-256 == 32 byte key
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // rand should never fail
+	}
+	return b
+}
+
+func NewIdentityKey() []byte {
+	return make([]byte, 16) // the key is not used to perform encryption but must be a valid AES key
+}
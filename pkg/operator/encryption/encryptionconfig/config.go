@@ -2,7 +2,10 @@ package encryptionconfig
 
 import (
 	"encoding/base64"
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -41,13 +44,22 @@ func FromEncryptionState(encryptionState map[schema.GroupResource]state.GroupRes
 // Read keys contain a potential write key. Read keys are sorted, recent first.
 //
 // It assumes:
-// - the first provider provides the write key
+// - the first provider provides the write key, specifically its first key if it carries more than one
 // - the structure of the encryptionConfig matches the output generated by FromEncryptionState:
 //   - one resource per provider
-//   - one key per provider
+//   - one or more keys per provider, contiguous same-mode keys coalesced into a single provider,
+//     write key (if any) first, followed by decrypt-only read keys, recent first
 // - each resource has a distinct configuration with zero or more key based providers and the identity provider.
-// - the last providers might be of type aesgcm. Then it carries the names of identity keys, recent first.
-//   We never use aesgcm as a real key because it is unsafe.
+// - the last providers might be of type aesgcm. Unless it carries the emptyStaticIdentityKey sentinel secret
+//   (in which case it merely curries the names of identity keys, recent first), it is a real AES-GCM write or
+//   read key: AES-GCM is only safe to use for real encryption as long as keys are rotated automatically, see
+//   state.AESGCMMaxWriteKeyAge. This package does not enforce that rotation cadence itself; it is the
+//   responsibility of the (out of scope here) key minting controller.
+// - kms providers carry their own endpoint/cachesize/timeout and may appear anywhere in the
+//   provider list, including as non-write, decrypt-only entries for retired KMS keyspaces.
+//   apiserverconfigv1.KMSConfiguration only exposes a single Name field, so it is used to carry
+//   both the apiserver-facing plugin name and our internal rotation keyID, joined as
+//   "<pluginName>-<keyID>" -- see splitKMSName.
 func ToEncryptionState(encryptionConfig *apiserverconfigv1.EncryptionConfiguration, keySecrets []*corev1.Secret) (map[schema.GroupResource]state.GroupResourceState, []state.KeyState) {
 	backedKeys := make([]state.KeyState, 0, len(keySecrets))
 	for _, s := range keySecrets {
@@ -76,49 +88,58 @@ func ToEncryptionState(encryptionConfig *apiserverconfigv1.EncryptionConfigurati
 		grState := state.GroupResourceState{}
 
 		for i, provider := range resourceConfig.Providers {
-			var ks state.KeyState
+			var keys []apiserverconfigv1.Key
+			var mode state.Mode
 
 			switch {
-			case provider.AESCBC != nil && len(provider.AESCBC.Keys) == 1:
-				ks = state.KeyState{
-					Key:  provider.AESCBC.Keys[0],
-					Mode: state.AESCBC,
-				}
+			case provider.AESCBC != nil && len(provider.AESCBC.Keys) > 0:
+				keys, mode = provider.AESCBC.Keys, state.AESCBC
 
-			case provider.Secretbox != nil && len(provider.Secretbox.Keys) == 1:
-				ks = state.KeyState{
-					Key:  provider.Secretbox.Keys[0],
-					Mode: state.SecretBox,
-				}
+			case provider.Secretbox != nil && len(provider.Secretbox.Keys) > 0:
+				keys, mode = provider.Secretbox.Keys, state.SecretBox
 
 			case provider.Identity != nil:
 				// skip fake provider. If this is write-key, wait for first aesgcm provider providing the write key.
 				continue
 
-			case provider.AESGCM != nil && len(provider.AESGCM.Keys) == 1 && provider.AESGCM.Keys[0].Secret == emptyStaticIdentityKey:
-				ks = state.KeyState{
-					Key:  provider.AESGCM.Keys[0],
-					Mode: state.Identity,
+			case provider.AESGCM != nil && len(provider.AESGCM.Keys) > 0:
+				keys, mode = provider.AESGCM.Keys, state.AESGCM
+
+			case provider.KMS != nil:
+				pluginName, keyID, ok := splitKMSName(provider.KMS.Name)
+				if !ok {
+					klog.Warningf("skipping invalid kms provider name %q for resource %s", provider.KMS.Name, resourceConfig.Resources[0])
+					continue // should never happen
 				}
+				ks := state.KeyState{
+					Key:  apiserverconfigv1.Key{Name: keyID},
+					Mode: state.KMS,
+					KMS: &state.KMSConfig{
+						Name:      pluginName,
+						Endpoint:  provider.KMS.Endpoint,
+						CacheSize: provider.KMS.CacheSize,
+						Timeout:   provider.KMS.Timeout,
+					},
+				}
+				grState = enrichAndRecord(grState, ks, backedKeys, i == 0)
+				continue
 
 			default:
 				klog.Infof("skipping invalid provider index %d for resource %s", i, resourceConfig.Resources[0])
 				continue // should never happen
 			}
 
-			// enrich KeyState with values from secrets
-			for _, k := range backedKeys {
-				if state.EqualKeyAndEqualID(&ks, &k) {
-					ks = k
-					break
+			// a provider may coalesce several keys: index 0 is the write key (only for the first
+			// provider), the rest are decrypt-only read keys carried along for migration.
+			for j, key := range keys {
+				ks := state.KeyState{Key: key, Mode: mode}
+				if mode == state.AESGCM && key.Secret == emptyStaticIdentityKey {
+					ks.Mode = state.Identity
 				}
-			}
 
-			if i == 0 || (ks.Mode == state.Identity && !grState.HasWriteKey()) {
-				grState.WriteKey = ks
+				isFirstKey := i == 0 && j == 0
+				grState = enrichAndRecord(grState, ks, backedKeys, isFirstKey)
 			}
-
-			grState.ReadKeys = append(grState.ReadKeys, ks) // also for write key as they are also read keys
 		}
 
 		// sort read-keys, recent first
@@ -130,10 +151,32 @@ func ToEncryptionState(encryptionConfig *apiserverconfigv1.EncryptionConfigurati
 	return out, backedKeys
 }
 
+// enrichAndRecord enriches ks with the values from a matching backed key (if any), records it as
+// the group resource's write key when isFirstKey is set (or, lacking any write key yet, when it is
+// an identity carrier), and always appends it to the read keys.
+func enrichAndRecord(grState state.GroupResourceState, ks state.KeyState, backedKeys []state.KeyState, isFirstKey bool) state.GroupResourceState {
+	for _, k := range backedKeys {
+		if state.EqualKeyAndEqualID(&ks, &k) {
+			ks = k
+			break
+		}
+	}
+
+	if isFirstKey || (ks.Mode == state.Identity && !grState.HasWriteKey()) {
+		grState.WriteKey = ks
+	}
+
+	grState.ReadKeys = append(grState.ReadKeys, ks) // also for write key as they are also read keys
+	return grState
+}
+
 // stateToProviders maps the write and read secrets to the equivalent read and write keys.
 // it primarily handles the conversion of KeyState to the appropriate provider config.
 // the identity mode is transformed into a custom aesgcm provider that simply exists to
 // curry the associated null key secret through the encryption state machine.
+// contiguous keys of the same key-based mode (aescbc, aesgcm, secretbox) are coalesced into a
+// single provider carrying all of them, write key first, to keep the config small when many
+// read keys are kept around during a migration.
 func stateToProviders(desired state.GroupResourceState) []apiserverconfigv1.ProviderConfiguration {
 	allKeys := desired.ReadKeys
 
@@ -155,30 +198,37 @@ func stateToProviders(desired state.GroupResourceState) []apiserverconfigv1.Prov
 		})
 	}
 
-	aesgcmProviders := []apiserverconfigv1.ProviderConfiguration{}
+	var identityCarrierKeys []apiserverconfigv1.Key
 	for i, key := range allKeys {
 		switch key.Mode {
 		case state.AESCBC:
-			providers = append(providers, apiserverconfigv1.ProviderConfiguration{
-				AESCBC: &apiserverconfigv1.AESConfiguration{
-					Keys: []apiserverconfigv1.Key{key.Key},
-				},
-			})
+			providers = appendOrCoalesce(providers, state.AESCBC, key.Key)
+		case state.AESGCM:
+			providers = appendOrCoalesce(providers, state.AESGCM, key.Key)
 		case state.SecretBox:
-			providers = append(providers, apiserverconfigv1.ProviderConfiguration{
-				Secretbox: &apiserverconfigv1.SecretboxConfiguration{
-					Keys: []apiserverconfigv1.Key{key.Key},
-				},
-			})
+			providers = appendOrCoalesce(providers, state.SecretBox, key.Key)
 		case state.Identity:
 			if i == 0 {
 				providers = append(providers, apiserverconfigv1.ProviderConfiguration{
 					Identity: &apiserverconfigv1.IdentityConfiguration{},
 				})
 			}
-			aesgcmProviders = append(aesgcmProviders, apiserverconfigv1.ProviderConfiguration{
-				AESGCM: &apiserverconfigv1.AESConfiguration{
-					Keys: []apiserverconfigv1.Key{key.Key},
+			identityCarrierKeys = append(identityCarrierKeys, key.Key)
+		case state.KMS:
+			// unlike identity, a kms provider carries its own name/endpoint and needs no fake
+			// carrier: retired (non-write) KMS keyspaces are simply emitted in place, the same
+			// way retired aescbc/secretbox read keys are, so the apiserver can still decrypt them.
+			if key.KMS == nil {
+				// this should never happen because our input should always be valid
+				klog.Warningf("skipping KMS key %s as it is missing its KMS config", key.Key.Name)
+				continue
+			}
+			providers = append(providers, apiserverconfigv1.ProviderConfiguration{
+				KMS: &apiserverconfigv1.KMSConfiguration{
+					Name:      fmt.Sprintf("%s-%s", key.KMS.Name, key.Key.Name),
+					Endpoint:  key.KMS.Endpoint,
+					CacheSize: key.KMS.CacheSize,
+					Timeout:   key.KMS.Timeout,
 				},
 			})
 		default:
@@ -194,8 +244,72 @@ func stateToProviders(desired state.GroupResourceState) []apiserverconfigv1.Prov
 		})
 	}
 
-	// add fake aesgm providers carrying identity names
-	providers = append(providers, aesgcmProviders...)
+	// add a single fake aesgcm provider carrying all identity names, recent first
+	if len(identityCarrierKeys) > 0 {
+		providers = append(providers, apiserverconfigv1.ProviderConfiguration{
+			AESGCM: &apiserverconfigv1.AESConfiguration{
+				Keys: identityCarrierKeys,
+			},
+		})
+	}
+
+	return providers
+}
+
+// splitKMSName splits a KMSConfiguration.Name of the form "<pluginName>-<keyID>" back into its two
+// parts. This is the convention stateToProviders uses to carry both the apiserver-facing plugin
+// name and our internal rotation keyID through the single Name field the real API exposes.
+func splitKMSName(name string) (pluginName, keyID string, ok bool) {
+	lastIdx := strings.LastIndex(name, "-")
+	if lastIdx < 0 {
+		return "", "", false
+	}
+	pluginName, keyID = name[:lastIdx], name[lastIdx+1:]
+	if _, err := strconv.ParseUint(keyID, 10, 0); err != nil {
+		return "", "", false
+	}
+	return pluginName, keyID, true
+}
+
+// appendOrCoalesce appends key under mode, merging it into the previous provider's Keys list if
+// that provider is already of the same mode. This keeps contiguous same-mode keys -- the common
+// case during key rotation, where a handful of old read keys trail the new write key -- in a
+// single provider block instead of one block per key.
+func appendOrCoalesce(providers []apiserverconfigv1.ProviderConfiguration, mode state.Mode, key apiserverconfigv1.Key) []apiserverconfigv1.ProviderConfiguration {
+	if len(providers) > 0 {
+		last := &providers[len(providers)-1]
+		switch mode {
+		case state.AESCBC:
+			if last.AESCBC != nil {
+				last.AESCBC.Keys = append(last.AESCBC.Keys, key)
+				return providers
+			}
+		case state.AESGCM:
+			if last.AESGCM != nil {
+				last.AESGCM.Keys = append(last.AESGCM.Keys, key)
+				return providers
+			}
+		case state.SecretBox:
+			if last.Secretbox != nil {
+				last.Secretbox.Keys = append(last.Secretbox.Keys, key)
+				return providers
+			}
+		}
+	}
 
+	switch mode {
+	case state.AESCBC:
+		providers = append(providers, apiserverconfigv1.ProviderConfiguration{
+			AESCBC: &apiserverconfigv1.AESConfiguration{Keys: []apiserverconfigv1.Key{key}},
+		})
+	case state.AESGCM:
+		providers = append(providers, apiserverconfigv1.ProviderConfiguration{
+			AESGCM: &apiserverconfigv1.AESConfiguration{Keys: []apiserverconfigv1.Key{key}},
+		})
+	case state.SecretBox:
+		providers = append(providers, apiserverconfigv1.ProviderConfiguration{
+			Secretbox: &apiserverconfigv1.SecretboxConfiguration{Keys: []apiserverconfigv1.Key{key}},
+		})
+	}
 	return providers
 }
@@ -0,0 +1,163 @@
+package encryptionconfig
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/config/v1"
+
+	"github.com/PavloVaida/library-go/pkg/operator/encryption/state"
+)
+
+var testGR = schema.GroupResource{Group: "", Resource: "secrets"}
+
+// roundtrip converts gr through FromEncryptionState and back via ToEncryptionState (with no
+// backing secrets, since these tests are only concerned with the config <-> state shape) and
+// returns the result for the single group resource under test.
+func roundtrip(t *testing.T, gr state.GroupResourceState) state.GroupResourceState {
+	t.Helper()
+
+	config := FromEncryptionState(map[schema.GroupResource]state.GroupResourceState{testGR: gr})
+	out, _ := ToEncryptionState(config, nil)
+
+	got, ok := out[testGR]
+	if !ok {
+		t.Fatalf("expected resource %s in round-tripped state, got %v", testGR, out)
+	}
+	return got
+}
+
+func TestSingleKeyRoundTrip(t *testing.T) {
+	key := state.KeyState{
+		Key:  apiserverconfigv1.Key{Name: "1", Secret: "c2VjcmV0LWtleS0xLXNlY3JldC1rZXktMQ=="},
+		Mode: state.AESCBC,
+	}
+	gr := state.GroupResourceState{
+		WriteKey: key,
+		ReadKeys: []state.KeyState{key},
+	}
+
+	config := FromEncryptionState(map[schema.GroupResource]state.GroupResourceState{testGR: gr})
+	providers := config.Resources[0].Providers
+	if len(providers) != 2 || providers[0].AESCBC == nil || len(providers[0].AESCBC.Keys) != 1 || providers[1].Identity == nil {
+		t.Fatalf("expected a single coalesced aescbc provider plus the fallback identity provider, got %#v", providers)
+	}
+
+	got := roundtrip(t, gr)
+	if !reflect.DeepEqual(got.WriteKey, key) {
+		t.Errorf("write key did not round trip: got %#v, want %#v", got.WriteKey, key)
+	}
+	if !reflect.DeepEqual(got.ReadKeys, []state.KeyState{key}) {
+		t.Errorf("read keys did not round trip: got %#v, want %#v", got.ReadKeys, []state.KeyState{key})
+	}
+}
+
+func TestCoalesceMixedAESGCMAndIdentityCarrier(t *testing.T) {
+	writeKey := state.KeyState{
+		Key:  apiserverconfigv1.Key{Name: "3", Secret: "cmVhbC1hZXMtZ2NtLXdyaXRlLWtleS0zMmJ5dGVzISE="},
+		Mode: state.AESGCM,
+	}
+	identity2 := state.KeyState{
+		Key:  apiserverconfigv1.Key{Name: "2", Secret: emptyStaticIdentityKey},
+		Mode: state.Identity,
+	}
+	identity1 := state.KeyState{
+		Key:  apiserverconfigv1.Key{Name: "1", Secret: emptyStaticIdentityKey},
+		Mode: state.Identity,
+	}
+	gr := state.GroupResourceState{
+		WriteKey: writeKey,
+		ReadKeys: []state.KeyState{writeKey, identity2, identity1},
+	}
+
+	providers := stateToProviders(gr)
+
+	if len(providers) != 3 {
+		t.Fatalf("expected 3 providers (real aesgcm write key, fallback identity, identity carrier), got %#v", providers)
+	}
+	if providers[0].AESGCM == nil || len(providers[0].AESGCM.Keys) != 1 || providers[0].AESGCM.Keys[0].Name != "3" {
+		t.Fatalf("expected first provider to be the real aesgcm write key, got %#v", providers[0])
+	}
+	if providers[1].Identity == nil {
+		t.Fatalf("expected second provider to be the fallback identity provider, got %#v", providers[1])
+	}
+	if providers[2].AESGCM == nil {
+		t.Fatalf("expected third provider to be the identity carrier, got %#v", providers[2])
+	}
+	wantCarrierNames := []string{"2", "1"}
+	var gotCarrierNames []string
+	for _, k := range providers[2].AESGCM.Keys {
+		gotCarrierNames = append(gotCarrierNames, k.Name)
+	}
+	if !reflect.DeepEqual(gotCarrierNames, wantCarrierNames) {
+		t.Errorf("identity carrier did not preserve recent-first order: got %v, want %v", gotCarrierNames, wantCarrierNames)
+	}
+
+	got := roundtrip(t, gr)
+	if !reflect.DeepEqual(got.WriteKey, writeKey) {
+		t.Errorf("write key did not round trip: got %#v, want %#v", got.WriteKey, writeKey)
+	}
+	want := []state.KeyState{writeKey, identity2, identity1}
+	if !reflect.DeepEqual(got.ReadKeys, want) {
+		t.Errorf("read keys did not round trip: got %#v, want %#v", got.ReadKeys, want)
+	}
+}
+
+func TestMultiKeyKMSAndAESCBCReadKeyOrdering(t *testing.T) {
+	cacheSize := int32(500)
+	timeout := metav1.Duration{Duration: 3 * time.Second}
+	writeKey := state.KeyState{
+		Key:  apiserverconfigv1.Key{Name: "5"},
+		Mode: state.KMS,
+		KMS: &state.KMSConfig{
+			Name:      "mykmsplugin",
+			Endpoint:  "unix:///var/run/kms-provider.sock",
+			CacheSize: &cacheSize,
+			Timeout:   &timeout,
+		},
+	}
+	read4 := state.KeyState{
+		Key:  apiserverconfigv1.Key{Name: "4", Secret: "b2xkLWFlc2NiYy1yZWFkLWtleS1udW1iZXItNA=="},
+		Mode: state.AESCBC,
+	}
+	read3 := state.KeyState{
+		Key:  apiserverconfigv1.Key{Name: "3", Secret: "b2xkLWFlc2NiYy1yZWFkLWtleS1udW1iZXItMw=="},
+		Mode: state.AESCBC,
+	}
+	gr := state.GroupResourceState{
+		WriteKey: writeKey,
+		ReadKeys: []state.KeyState{writeKey, read4, read3},
+	}
+
+	providers := stateToProviders(gr)
+
+	if len(providers) != 3 {
+		t.Fatalf("expected 3 providers (kms write key, coalesced aescbc read keys, fallback identity), got %#v", providers)
+	}
+	if providers[0].KMS == nil || providers[0].KMS.Name != "mykmsplugin-5" {
+		t.Fatalf("expected first provider to be the kms write key, its plugin name distinct from its keyID, got %#v", providers[0])
+	}
+	if providers[1].AESCBC == nil || len(providers[1].AESCBC.Keys) != 2 {
+		t.Fatalf("expected second provider to coalesce both aescbc read keys, got %#v", providers[1])
+	}
+	wantReadKeyNames := []string{"4", "3"}
+	var gotReadKeyNames []string
+	for _, k := range providers[1].AESCBC.Keys {
+		gotReadKeyNames = append(gotReadKeyNames, k.Name)
+	}
+	if !reflect.DeepEqual(gotReadKeyNames, wantReadKeyNames) {
+		t.Errorf("aescbc read keys lost their recent-first order: got %v, want %v", gotReadKeyNames, wantReadKeyNames)
+	}
+
+	got := roundtrip(t, gr)
+	if !reflect.DeepEqual(got.WriteKey, writeKey) {
+		t.Errorf("kms write key did not round trip: got %#v, want %#v", got.WriteKey, writeKey)
+	}
+	want := []state.KeyState{writeKey, read4, read3}
+	if !reflect.DeepEqual(got.ReadKeys, want) {
+		t.Errorf("read keys did not round trip in order: got %#v, want %#v", got.ReadKeys, want)
+	}
+}
@@ -0,0 +1,194 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/config/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/PavloVaida/library-go/pkg/operator/encryption/state"
+)
+
+// ToKeyState converts a key secret to a key state.
+func ToKeyState(s *corev1.Secret) (state.KeyState, error) {
+	keyID, validKeyID := state.NameToKeyID(s.Name)
+	if !validKeyID {
+		return state.KeyState{}, fmt.Errorf("secret %s/%s has an invalid name", s.Namespace, s.Name)
+	}
+
+	key := state.KeyState{
+		Key: apiserverconfigv1.Key{
+			// we use keyID as the name to limit the length of the field as it is used as a prefix for every value in etcd
+			Name: strconv.FormatUint(keyID, 10),
+		},
+		Backed: true,
+	}
+
+	if v, ok := s.Annotations[EncryptionSecretMigratedTimestamp]; ok {
+		ts, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return state.KeyState{}, fmt.Errorf("secret %s/%s has invalid %s annotation: %v", s.Namespace, s.Name, EncryptionSecretMigratedTimestamp, err)
+		}
+		key.Migrated.Timestamp = ts
+	}
+
+	if v, ok := s.Annotations[EncryptionSecretMigratedResources]; ok && len(v) > 0 {
+		migrated := &MigratedGroupResources{}
+		if err := json.Unmarshal([]byte(v), migrated); err != nil {
+			return state.KeyState{}, fmt.Errorf("secret %s/%s has invalid %s annotation: %v", s.Namespace, s.Name, EncryptionSecretMigratedResources, err)
+		}
+		key.Migrated.Resources = migrated.Resources
+	}
+
+	if v, ok := s.Annotations[encryptionSecretInternalReason]; ok && len(v) > 0 {
+		key.InternalReason = v
+	}
+	if v, ok := s.Annotations[encryptionSecretExternalReason]; ok && len(v) > 0 {
+		key.ExternalReason = v
+	}
+
+	keyMode := state.Mode(s.Annotations[encryptionSecretMode])
+	switch keyMode {
+	case state.AESCBC, state.AESGCM, state.SecretBox, state.Identity:
+		key.Mode = keyMode
+		key.Key.Secret = base64.StdEncoding.EncodeToString(s.Data[EncryptionSecretKeyDataKey])
+		if keyMode != state.Identity && len(s.Data[EncryptionSecretKeyDataKey]) == 0 {
+			return state.KeyState{}, fmt.Errorf("secret %s/%s of mode %q must have non-empty key", s.Namespace, s.Name, keyMode)
+		}
+
+	case state.KMS:
+		key.Mode = keyMode
+		kmsCfg, err := kmsConfigFromData(s.Data[EncryptionSecretKMSConfigDataKey])
+		if err != nil {
+			return state.KeyState{}, fmt.Errorf("secret %s/%s has invalid KMS config: %v", s.Namespace, s.Name, err)
+		}
+		key.KMS = kmsCfg
+
+	default:
+		return state.KeyState{}, fmt.Errorf("secret %s/%s has invalid mode: %s", s.Namespace, s.Name, keyMode)
+	}
+
+	return key, nil
+}
+
+// FromKeyState converts a key state to a key secret.
+func FromKeyState(component string, ks state.KeyState) (*corev1.Secret, error) {
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("encryption-key-%s-%s", component, ks.Key.Name),
+			Namespace: "openshift-config-managed",
+			Labels: map[string]string{
+				EncryptionKeySecretsLabel: component,
+			},
+			Annotations: map[string]string{
+				state.KubernetesDescriptionKey: state.KubernetesDescriptionScaryValue,
+
+				encryptionSecretMode:           string(ks.Mode),
+				encryptionSecretInternalReason: ks.InternalReason,
+				encryptionSecretExternalReason: ks.ExternalReason,
+			},
+			Finalizers: []string{EncryptionSecretFinalizer},
+		},
+		Data: map[string][]byte{},
+	}
+
+	switch ks.Mode {
+	case state.KMS:
+		bs, err := kmsConfigToData(ks.KMS)
+		if err != nil {
+			return nil, err
+		}
+		s.Data[EncryptionSecretKMSConfigDataKey] = bs
+
+	default:
+		bs, err := base64.StdEncoding.DecodeString(ks.Key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key string")
+		}
+		s.Data[EncryptionSecretKeyDataKey] = bs
+	}
+
+	if !ks.Migrated.Timestamp.IsZero() {
+		s.Annotations[EncryptionSecretMigratedTimestamp] = ks.Migrated.Timestamp.Format(time.RFC3339)
+	}
+	if len(ks.Migrated.Resources) > 0 {
+		migrated := MigratedGroupResources{Resources: ks.Migrated.Resources}
+		bs, err := json.Marshal(migrated)
+		if err != nil {
+			return nil, err
+		}
+		s.Annotations[EncryptionSecretMigratedResources] = string(bs)
+	}
+
+	return s, nil
+}
+
+// kmsConfigFromData decodes the JSON blob persisted under EncryptionSecretKMSConfigDataKey.
+func kmsConfigFromData(data []byte) (*state.KMSConfig, error) {
+	cfg := kmsConfig{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	out := &state.KMSConfig{
+		Name:      cfg.Name,
+		Endpoint:  cfg.Endpoint,
+		CacheSize: cfg.CacheSize,
+	}
+	if len(cfg.Timeout) > 0 {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %v", cfg.Timeout, err)
+		}
+		out.Timeout = &metav1.Duration{Duration: d}
+	}
+	return out, nil
+}
+
+// kmsConfigToData encodes ks into the JSON blob persisted under EncryptionSecretKMSConfigDataKey.
+func kmsConfigToData(ks *state.KMSConfig) ([]byte, error) {
+	if ks == nil {
+		return nil, fmt.Errorf("KMS key state is missing its KMS config")
+	}
+
+	cfg := kmsConfig{
+		Name:      ks.Name,
+		Endpoint:  ks.Endpoint,
+		CacheSize: ks.CacheSize,
+	}
+	if ks.Timeout != nil {
+		cfg.Timeout = ks.Timeout.Duration.String()
+	}
+	return json.Marshal(cfg)
+}
+
+// HasResource returns whether the given group resource is contained in the migrated group resource list.
+func (m *MigratedGroupResources) HasResource(resource schema.GroupResource) bool {
+	for _, gr := range m.Resources {
+		if gr == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// ListKeySecrets returns the current key secrets from openshift-config-managed.
+func ListKeySecrets(ctx context.Context, secretClient corev1client.SecretsGetter, encryptionSecretSelector metav1.ListOptions) ([]*corev1.Secret, error) {
+	encryptionSecretList, err := secretClient.Secrets("openshift-config-managed").List(ctx, encryptionSecretSelector)
+	if err != nil {
+		return nil, err
+	}
+	var encryptionSecrets []*corev1.Secret
+	for i := range encryptionSecretList.Items {
+		encryptionSecrets = append(encryptionSecrets, &encryptionSecretList.Items[i])
+	}
+	return encryptionSecrets, nil
+}
@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/config/v1"
+
+	"github.com/PavloVaida/library-go/pkg/operator/encryption/state"
+)
+
+func TestKMSKeyStateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		kms  *state.KMSConfig
+	}{
+		{
+			name: "unset cache size and timeout",
+			kms: &state.KMSConfig{
+				Name:     "mykmsplugin",
+				Endpoint: "unix:///var/run/kms-provider.sock",
+			},
+		},
+		{
+			name: "explicit zero cache size and timeout",
+			kms: &state.KMSConfig{
+				Name:      "mykmsplugin",
+				Endpoint:  "unix:///var/run/kms-provider.sock",
+				CacheSize: int32Ptr(0),
+				Timeout:   &metav1.Duration{Duration: 0},
+			},
+		},
+		{
+			name: "populated cache size and timeout",
+			kms: &state.KMSConfig{
+				Name:      "mykmsplugin",
+				Endpoint:  "unix:///var/run/kms-provider.sock",
+				CacheSize: int32Ptr(1000),
+				Timeout:   &metav1.Duration{Duration: 3 * time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := state.KeyState{
+				Key:  apiserverconfigv1.Key{Name: "7"},
+				Mode: state.KMS,
+				KMS:  tt.kms,
+			}
+
+			s, err := FromKeyState("test", ks)
+			if err != nil {
+				t.Fatalf("FromKeyState failed: %v", err)
+			}
+
+			got, err := ToKeyState(s)
+			if err != nil {
+				t.Fatalf("ToKeyState failed: %v", err)
+			}
+			got.Backed = false // Backed is set by ToKeyState but absent from ks
+
+			if !reflect.DeepEqual(got, ks) {
+				t.Errorf("KMS key state did not round trip: got %#v, want %#v", got, ks)
+			}
+		})
+	}
+}
+
+func TestKMSConfigFromDataInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "missing data", data: nil},
+		{name: "malformed JSON", data: []byte("{not json")},
+		{name: "invalid timeout", data: []byte(`{"name":"p","endpoint":"e","timeout":"not-a-duration"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := kmsConfigFromData(tt.data); err == nil {
+				t.Errorf("expected an error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,113 @@
+package state
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/config/v1"
+)
+
+// These annotations try to scare anyone away from editing the encryption secrets.  It is trivial for
+// an external actor to break the invariants of the state machine and render the cluster unrecoverable.
+const (
+	KubernetesDescriptionKey        = "kubernetes.io/description"
+	KubernetesDescriptionScaryValue = `WARNING: DO NOT EDIT.
+Altering of the encryption secrets will render you cluster inaccessible.
+Catastrophic data loss can occur from the most minor changes.`
+)
+
+// GroupResourceState represents, for a single group resource, the write and read keys in a
+// format that can be directly translated to and from the on disk EncryptionConfiguration object.
+type GroupResourceState struct {
+	// the write key of the group resource.
+	WriteKey KeyState
+	// all read keys of the group resource. Potentially includes the write key.
+	ReadKeys []KeyState
+}
+
+func (k GroupResourceState) HasWriteKey() bool {
+	return len(k.WriteKey.Key.Name) > 0 && (len(k.WriteKey.Key.Secret) > 0 || k.WriteKey.KMS != nil)
+}
+
+type KeyState struct {
+	Key  apiserverconfigv1.Key
+	Mode Mode
+
+	// described whether it is backed by a secret.
+	Backed   bool
+	Migrated MigrationState
+	// some controller logic caused this secret to be created by the key controller.
+	InternalReason string
+	// the user via unsupportConfigOverrides.encryption.reason triggered this key.
+	ExternalReason string
+
+	// KMS carries the out-of-process KMS plugin configuration for keys with Mode == KMS.
+	// It is populated in place of Key.Secret, since the operator never sees (and cannot
+	// mint) the data encryption key material for a KMS keyspace -- that lives behind the
+	// plugin endpoint. Key.Name is still used as the keyID for rotation bookkeeping, the
+	// same as every other mode; the KMS plugin name lives in KMS.Name instead.
+	KMS *KMSConfig
+}
+
+// HasKMS reports whether k carries a KMS plugin configuration.
+func (k *KeyState) HasKMS() bool {
+	return k != nil && k.KMS != nil
+}
+
+// KMSConfig is the subset of apiserverconfigv1.KMSConfiguration that the operator needs to
+// persist and reconstruct a kms provider block for a single KMS keyspace.
+type KMSConfig struct {
+	// Name is the KMS plugin name the apiserver uses to address this specific plugin, for
+	// example "mykmsplugin". This is distinct from the owning KeyState's Key.Name, which is
+	// always the rotation keyID, the same as every other mode.
+	Name string
+	// Endpoint is the unix socket the apiserver dials to reach the KMS plugin,
+	// for example "unix:///var/run/kms-provider.sock".
+	Endpoint string
+	// CacheSize bounds how many decrypted data encryption keys the apiserver may
+	// cache in memory. A nil value means the apiserver default is used; this is
+	// distinct from an explicit 0 (or negative, which disables caching).
+	CacheSize *int32
+	// Timeout bounds how long the apiserver waits for the plugin to answer a single
+	// gRPC call. A nil value means the apiserver default is used; this is distinct
+	// from an explicit zero duration.
+	Timeout *metav1.Duration
+}
+
+type MigrationState struct {
+	// the timestamp fo the last migration
+	Timestamp time.Time
+	// the resources that were migrated at some point in time to this key.
+	Resources []schema.GroupResource
+}
+
+// Mode is the value associated with the encryptionSecretMode annotation
+type Mode string
+
+// The current set of modes that are supported along with the default Mode that is used.
+// These values are encoded into the secret and thus must not be changed.
+// Strings are used over iota because they are easier for a human to understand.
+const (
+	AESCBC    Mode = "aescbc"    // available from the first release, see DefaultMode below
+	AESGCM    Mode = "aesgcm"    // real, authenticated AES-GCM write key; automated rotation is mandatory but NOT enforced in this repo slice, see AESGCMMaxWriteKeyAge below
+	SecretBox Mode = "secretbox" // available from the first release, see DefaultMode below
+	Identity  Mode = "identity"  // available from the first release, see DefaultMode below
+	KMS       Mode = "kms"       // out-of-process envelope encryption via a KMS plugin
+
+	// Changing this value requires caution to not break downgrades.
+	// Specifically, if some new Mode is released in version X, that new Mode cannot
+	// be used as the DefaultMode until version X+1.  Thus on a downgrade the operator
+	// from version X will still be able to honor the observed encryption state
+	// (and it will do a key rotation to force the use of the old DefaultMode).
+	DefaultMode = Identity // we default to encryption being disabled for now
+
+	// AESGCMMaxWriteKeyAge is the documented invariant that backs the safe use of AESGCM as a
+	// real write key: nonce reuse under a fixed key is catastrophic for GCM, so a write key
+	// must never be used for longer than this before it is rotated.
+	//
+	// This package only records the invariant; it is not enforced here. Enforcing it (and
+	// exposing an opt-in knob for operators to pick AESGCM as their provider) is the job of
+	// the key minting controller, which is not part of this repo slice.
+	AESGCMMaxWriteKeyAge = 7 * 24 * time.Hour
+)